@@ -0,0 +1,229 @@
+/*
+Copyright 2020 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache stores GitHub API responses keyed by request URL, along with the ETag and response
+// headers they were served with, so a later request can be made conditional (If-None-Match) and
+// skip the rate limit on a 304. The headers must be replayed alongside the cached body on a
+// cache hit: in particular GitHub omits the Link header (which go-github's pagination relies on)
+// from 304 responses, so losing it would silently truncate pagination on every cache hit.
+// Get reports ok=false on a miss.
+type Cache interface {
+	Get(url string) (etag string, header http.Header, body []byte, ok bool)
+	Set(url, etag string, header http.Header, body []byte)
+}
+
+// WithCache returns a copy of o with Cache set, for use as:
+//
+//	opts := update.Options{}.WithCache(cache)
+func (o Options) WithCache(c Cache) Options {
+	o.Cache = c
+	return o
+}
+
+// MemCache is an in-memory Cache suitable for sharing across calls within a single process.
+type MemCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewMemCache returns an empty in-memory Cache.
+func NewMemCache() *MemCache {
+	return &MemCache{entries: map[string]cacheEntry{}}
+}
+
+// Get implements Cache.
+func (c *MemCache) Get(url string) (string, http.Header, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		return "", nil, nil, false
+	}
+	return e.ETag, e.Header, e.Body, true
+}
+
+// Set implements Cache.
+func (c *MemCache) Set(url, etag string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = cacheEntry{ETag: etag, Header: header, Body: body}
+}
+
+type cacheEntry struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// FileCache is a Cache that persists its entries under
+// $XDG_CACHE_HOME/minikube-update/<owner>/<repo>.json, so separate hack/update generators
+// running in the same CI job (kubeadm images, kubernetes versions, driver versions, etc.) can
+// share one on-disk cache and avoid re-paginating GitHub on every invocation.
+//
+// Writes are atomic (via a temp file + rename) so a reader never observes a half-written file,
+// but FileCache only takes an in-process lock: it does not coordinate load-modify-save across
+// separate OS processes. Generators that run concurrently in the same job may race and clobber
+// each other's entries on write; the worst case is a lost cache entry (an extra GitHub request
+// next time), not a corrupt cache.
+type FileCache struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCache returns a FileCache for owner/repo rooted at $XDG_CACHE_HOME (or ~/.cache if
+// unset).
+func NewFileCache(owner, repo string) (*FileCache, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return &FileCache{path: filepath.Join(base, "minikube-update", owner, repo+".json")}, nil
+}
+
+// Get implements Cache.
+func (c *FileCache) Get(url string) (string, http.Header, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		return "", nil, nil, false
+	}
+	e, ok := entries[url]
+	if !ok {
+		return "", nil, nil, false
+	}
+	return e.ETag, e.Header, e.Body, true
+}
+
+// Set implements Cache.
+func (c *FileCache) Set(url, etag string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		entries = map[string]cacheEntry{}
+	}
+	entries[url] = cacheEntry{ETag: etag, Header: header, Body: body}
+	c.save(entries)
+}
+
+func (c *FileCache) load() (map[string]cacheEntry, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]cacheEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// save writes entries atomically: it writes to a temp file in the same directory and renames it
+// over c.path, so a concurrent reader never observes a partially-written cache file.
+func (c *FileCache) save(entries map[string]cacheEntry) error {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// cachingTransport is an http.RoundTripper that makes GET requests conditional on a cached ETag
+// and serves a cached body on a 304 response, recording any new ETag it observes.
+type cachingTransport struct {
+	base  http.RoundTripper
+	cache Cache
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || t.cache == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	etag, header, body, cached := t.cache.Get(key)
+	// An entry stored without headers can't be
+	// safely replayed on a 304, since GitHub won't resend headers like Link on that response -
+	// treat it as a miss so this request refetches and repopulates the entry with headers.
+	if cached && header == nil {
+		cached = false
+	}
+	if cached {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached {
+		// GitHub's 304 responses don't resend headers like Link, which go-github's pagination
+		// depends on, so the cached headers captured from the original 200 must be replayed.
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Header = header.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if newETag := resp.Header.Get("ETag"); newETag != "" {
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return resp, err
+			}
+			t.cache.Set(key, newETag, resp.Header.Clone(), data)
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+		}
+	}
+	return resp, nil
+}
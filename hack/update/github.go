@@ -19,9 +19,15 @@ package update
 import (
 	"context"
 	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/semver"
+	"golang.org/x/oauth2"
 
 	"github.com/google/go-github/v54/github"
 )
@@ -33,24 +39,202 @@ const (
 
 	// ghSearchLimit limits the number of searched items to be <= N * ghListPerPage.
 	ghSearchLimit = 300
+
+	// ghDefaultRetries is the number of times a transient GitHub API failure is retried by default.
+	ghDefaultRetries = 3
+
+	// ghDefaultBackoff is the base delay used for exponential backoff between retries.
+	ghDefaultBackoff = 2 * time.Second
 )
 
+// Options configures how GHReleasesWithOptions talks to the GitHub API.
+type Options struct {
+	// Token is a GitHub personal access token used to authenticate requests. If empty,
+	// GHReleasesWithOptions falls back to the GITHUB_TOKEN or GITHUB_PAT environment variables.
+	Token string
+	// HTTPClient, if set, is used instead of constructing an authenticated client from Token.
+	// When Cache is also set, a new client is built that reuses only HTTPClient's Transport
+	// (wrapped with the cache) and Timeout.
+	HTTPClient *http.Client
+	// Retries is the number of times to retry a request that failed with a transient error
+	// (5xx responses or a primary or secondary rate limit). Defaults to ghDefaultRetries.
+	Retries int
+	// Backoff is the base delay for exponential backoff between retries. Defaults to ghDefaultBackoff.
+	Backoff time.Duration
+	// PerPage overrides ghListPerPage.
+	PerPage int
+	// SearchLimit overrides ghSearchLimit.
+	SearchLimit int
+	// Cache, if set, makes GET requests conditional on a cached ETag so an unchanged response
+	// costs a 304 instead of a full hit against the rate limit. See WithCache.
+	Cache Cache
+}
+
+// ghClient builds a github.Client from opts, authenticating with opts.Token (or the
+// GITHUB_TOKEN/GITHUB_PAT environment variables) when no HTTPClient is provided, and wrapping
+// the resulting transport with opts.Cache when set.
+func ghClient(ctx context.Context, opts Options) *github.Client {
+	var hc *http.Client
+	if opts.HTTPClient != nil {
+		hc = opts.HTTPClient
+		if opts.Cache != nil {
+			base := hc.Transport
+			if base == nil {
+				base = http.DefaultTransport
+			}
+			hc = &http.Client{Transport: &cachingTransport{base: base, cache: opts.Cache}, Timeout: hc.Timeout}
+		}
+		return github.NewClient(hc)
+	}
+
+	token := opts.Token
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		token = os.Getenv("GITHUB_PAT")
+	}
+	if token == "" {
+		hc = &http.Client{}
+	} else {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		hc = oauth2.NewClient(ctx, ts)
+	}
+
+	if opts.Cache != nil {
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		hc.Transport = &cachingTransport{base: base, cache: opts.Cache}
+	}
+	return github.NewClient(hc)
+}
+
+// ghRetry calls fn, retrying on transient errors (5xx responses and rate limits) with
+// exponential backoff, honoring any X-RateLimit-Reset/Retry-After hint on the response.
+func ghRetry(ctx context.Context, opts Options, fn func() (*github.Response, error)) error {
+	retries := opts.Retries
+	if retries <= 0 {
+		retries = ghDefaultRetries
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = ghDefaultBackoff
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		_, err = fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == retries || !ghRetryable(err) {
+			return err
+		}
+
+		delay := time.Duration(math.Pow(2, float64(attempt))) * backoff
+		switch e := err.(type) {
+		case *github.RateLimitError:
+			if wait := time.Until(e.Rate.Reset.Time); wait > 0 {
+				delay = wait
+			}
+		case *github.AbuseRateLimitError:
+			if e.RetryAfter != nil {
+				delay = *e.RetryAfter
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
+
+// ghRetryable reports whether err represents a transient failure worth retrying: a 5xx response
+// or a GitHub rate limit (primary or secondary/abuse) error.
+func ghRetryable(err error) bool {
+	if _, ok := err.(*github.RateLimitError); ok {
+		return true
+	}
+	if _, ok := err.(*github.AbuseRateLimitError); ok {
+		return true
+	}
+	if ge, ok := err.(*github.ErrorResponse); ok && ge.Response != nil {
+		return ge.Response.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
 type Release struct {
 	Tag    string
 	Commit string
+	// Notes holds the release's notes body, when populated by a caller such as
+	// GHReleaseNotesBetween. GHReleases itself leaves this empty.
+	Notes string
 }
 
-// GHReleases returns greatest current stable release and greatest latest rc or beta pre-release from GitHub owner/repo repository, and any error occurred.
-// If latest pre-release version is lower than the current stable release, then it will return current stable release for both.
-func GHReleases(ctx context.Context, owner, repo string) (stable, latest, edge Release, err error) {
-	ghc := github.NewClient(nil)
+// Channel identifies one of the release trains a Scanner watches for (e.g. stable, latest, edge).
+// Match is evaluated against the semver prerelease suffix (the output of semver.Prerelease,
+// e.g. "-rc.0", "-beta.2", or "" for a stable release) and decides whether a given tag belongs
+// to this channel.
+type Channel struct {
+	Name  string
+	Match func(prerelease string) bool
+}
+
+// Scanner walks a repository's releases looking for the greatest tag in each of Channels,
+// stopping early once StopWhen reports the walk has found everything it needs.
+type Scanner struct {
+	Owner, Repo string
+	Channels    []Channel
+	// StopWhen is consulted after every page of releases; if it returns true the scan stops
+	// even if more pages remain. A nil StopWhen scans every page up to the search limit.
+	StopWhen func(found map[string]Release) bool
+	Options
+}
+
+// stableChannels are the three channels GHReleases has historically tracked: stable releases,
+// rc/beta pre-releases ("latest"), and alpha pre-releases ("edge").
+func stableChannels() []Channel {
+	return []Channel{
+		{Name: "stable", Match: func(prerelease string) bool { return prerelease == "" }},
+		{Name: "latest", Match: func(prerelease string) bool {
+			return strings.HasPrefix(prerelease, "-rc") || strings.HasPrefix(prerelease, "-beta")
+		}},
+		{Name: "edge", Match: func(prerelease string) bool { return strings.Contains(prerelease, "-alpha") }},
+	}
+}
 
-	// walk through the paginated list of up to ghSearchLimit newest releases
-	opts := &github.ListOptions{PerPage: ghListPerPage}
-	for (opts.Page+1)*ghListPerPage <= ghSearchLimit {
-		rls, resp, err := ghc.Repositories.ListReleases(ctx, owner, repo, opts)
+// Scan returns the greatest release tag matching each of s.Channels, keyed by channel name, and
+// any error occurred. Channels are treated as an ascending priority order: once a later channel
+// falls behind an earlier one (e.g. "latest" behind "stable") it is raised to match, mirroring
+// the GHReleases guarantee that latest >= stable and edge >= latest.
+func (s Scanner) Scan(ctx context.Context) (map[string]Release, error) {
+	ghc := ghClient(ctx, s.Options)
+	perPage := s.PerPage
+	if perPage <= 0 {
+		perPage = ghListPerPage
+	}
+	searchLimit := s.SearchLimit
+	if searchLimit <= 0 {
+		searchLimit = ghSearchLimit
+	}
+
+	found := map[string]Release{}
+	opt := &github.ListOptions{PerPage: perPage}
+	for (opt.Page+1)*perPage <= searchLimit {
+		var rls []*github.RepositoryRelease
+		var resp *github.Response
+		err := ghRetry(ctx, s.Options, func() (*github.Response, error) {
+			var ferr error
+			rls, resp, ferr = ghc.Repositories.ListReleases(ctx, s.Owner, s.Repo, opt)
+			return resp, ferr
+		})
 		if err != nil {
-			return stable, latest, edge, err
+			return found, err
 		}
 		for _, rl := range rls {
 			ver := rl.GetTagName()
@@ -59,75 +243,202 @@ func GHReleases(ctx context.Context, owner, repo string) (stable, latest, edge R
 			}
 			// check if ver version is release (ie, 'v1.19.2') or pre-release (ie, 'v1.19.3-rc.0' or 'v1.19.0-beta.2')
 			prerls := semver.Prerelease(ver)
-			if prerls == "" {
-				if semver.Compare(ver, stable.Tag) == 1 {
-					stable.Tag = ver
+			for _, ch := range s.Channels {
+				if !ch.Match(prerls) {
+					continue
 				}
-			} else if strings.HasPrefix(prerls, "-rc") || strings.HasPrefix(prerls, "-beta") {
-				if semver.Compare(ver, latest.Tag) == 1 {
-					latest.Tag = ver
+				if cur, ok := found[ch.Name]; !ok || semver.Compare(ver, cur.Tag) == 1 {
+					found[ch.Name] = Release{Tag: ver}
 				}
-			} else if strings.Contains(prerls, "-alpha") {
-				if semver.Compare(ver, edge.Tag) == 1 {
-					edge.Tag = ver
-				}
-			}
-
-			// make sure that latest >= stable
-			if semver.Compare(latest.Tag, stable.Tag) == -1 {
-				latest.Tag = stable.Tag
+				break
 			}
-			// make sure that edge >= latest
-			if semver.Compare(edge.Tag, latest.Tag) == -1 {
-				edge.Tag = latest.Tag
+			// keep each channel's tag at or above the previous (lower-priority) channel's tag
+			for i := 1; i < len(s.Channels); i++ {
+				prev, cur := s.Channels[i-1].Name, s.Channels[i].Name
+				if semver.Compare(found[cur].Tag, found[prev].Tag) == -1 {
+					found[cur] = found[prev]
+				}
 			}
 		}
+		if s.StopWhen != nil && s.StopWhen(found) {
+			break
+		}
 		if resp.NextPage == 0 {
 			break
 		}
-		opts.Page = resp.NextPage
+		opt.Page = resp.NextPage
 	}
+	return found, nil
+}
+
+// GHReleases returns greatest current stable release and greatest latest rc or beta pre-release from GitHub owner/repo repository, and any error occurred.
+// If latest pre-release version is lower than the current stable release, then it will return current stable release for both.
+func GHReleases(ctx context.Context, owner, repo string) (stable, latest, edge Release, err error) {
+	return GHReleasesWithOptions(ctx, owner, repo, Options{})
+}
+
+// GHReleasesWithOptions behaves like GHReleases but allows the caller to customize
+// authentication, retry behaviour and pagination via opts.
+func GHReleasesWithOptions(ctx context.Context, owner, repo string, opts Options) (stable, latest, edge Release, err error) {
+	scanner := Scanner{Owner: owner, Repo: repo, Channels: stableChannels(), Options: opts}
+	found, err := scanner.Scan(ctx)
+	if err != nil {
+		return stable, latest, edge, err
+	}
+	stable, latest, edge = found["stable"], found["latest"], found["edge"]
+
 	// create a map where the key is the tag and the values is an array of releases (stable, latest, edge) that match the tag
 	releasesWithoutCommits := map[string][]*Release{}
 	for _, rl := range []*Release{&stable, &latest, &edge} {
 		releasesWithoutCommits[rl.Tag] = append(releasesWithoutCommits[rl.Tag], rl)
 	}
-	// run though the releases to find ones that don't yet have a commit and assign it
-	opts = &github.ListOptions{PerPage: ghListPerPage}
-	for (opts.Page+1)*ghListPerPage <= ghSearchLimit {
-		tags, resp, err := ghc.Repositories.ListTags(ctx, owner, repo, opts)
+	// resolve each release's tag to a commit SHA via ResolveCommit, which looks up the tag's ref
+	// directly instead of paginating ListTags, so finding the commit does not depend on tag age
+	for tag, rls := range releasesWithoutCommits {
+		if tag == "" {
+			continue
+		}
+		sha, err := ResolveCommitWithOptions(ctx, owner, repo, tag, opts)
 		if err != nil {
 			return stable, latest, edge, err
 		}
-		for _, tag := range tags {
-			rls, ok := releasesWithoutCommits[*tag.Name]
-			if !ok {
-				continue
-			}
-			for _, rl := range rls {
-				rl.Commit = *tag.Commit.SHA
-			}
-			delete(releasesWithoutCommits, *tag.Name)
-			if len(releasesWithoutCommits) == 0 {
-				return stable, latest, edge, nil
-			}
-		}
-		if len(releasesWithoutCommits) == 0 {
-			break
-		}
-		if resp.NextPage == 0 {
-			break
+		for _, rl := range rls {
+			rl.Commit = sha
 		}
-		opts.Page = resp.NextPage
 	}
 
-	return stable, latest, edge, fmt.Errorf("wasn't able to find commit for releases")
+	return stable, latest, edge, nil
+}
+
+// ResolveCommit resolves tag to the commit SHA it points at. Lightweight tags reference a commit
+// directly; annotated tags reference a tag object that is dereferenced via the Git Data API to
+// reach the underlying commit.
+func ResolveCommit(ctx context.Context, owner, repo, tag string) (string, error) {
+	return ResolveCommitWithOptions(ctx, owner, repo, tag, Options{})
+}
+
+// ResolveCommitWithOptions behaves like ResolveCommit but allows the caller to customize
+// authentication and retry behaviour via opts.
+func ResolveCommitWithOptions(ctx context.Context, owner, repo, tag string, opts Options) (string, error) {
+	ghc := ghClient(ctx, opts)
+
+	var ref *github.Reference
+	err := ghRetry(ctx, opts, func() (*github.Response, error) {
+		var ferr error
+		var resp *github.Response
+		ref, resp, ferr = ghc.Git.GetRef(ctx, owner, repo, "tags/"+tag)
+		return resp, ferr
+	})
+	if err != nil {
+		return "", err
+	}
+
+	obj := ref.GetObject()
+	if obj.GetType() != "tag" {
+		return obj.GetSHA(), nil
+	}
+
+	var tagObj *github.Tag
+	err = ghRetry(ctx, opts, func() (*github.Response, error) {
+		var ferr error
+		var resp *github.Response
+		tagObj, resp, ferr = ghc.Git.GetTag(ctx, owner, repo, obj.GetSHA())
+		return resp, ferr
+	})
+	if err != nil {
+		return "", err
+	}
+	return tagObj.GetObject().GetSHA(), nil
 }
 
+// StableVersion returns the tag of the greatest current stable release from GitHub owner/repo
+// repository, and any error occurred. It first tries GitHub's "latest release" endpoint, which
+// returns in a single request whichever non-prerelease the repo's maintainers marked "latest";
+// if that isn't a valid stable semver tag (or the repo has no releases), it falls back to
+// scanning the full release list via GHReleases.
 func StableVersion(ctx context.Context, owner, repo string) (string, error) {
-	stable, _, _, err := GHReleases(ctx, owner, repo)
+	return StableVersionWithOptions(ctx, owner, repo, Options{})
+}
+
+// StableVersionWithOptions behaves like StableVersion but allows the caller to customize
+// authentication, retry behaviour and pagination via opts.
+func StableVersionWithOptions(ctx context.Context, owner, repo string, opts Options) (string, error) {
+	ghc := ghClient(ctx, opts)
+
+	var rl *github.RepositoryRelease
+	err := ghRetry(ctx, opts, func() (*github.Response, error) {
+		var ferr error
+		var resp *github.Response
+		rl, resp, ferr = ghc.Repositories.GetLatestRelease(ctx, owner, repo)
+		return resp, ferr
+	})
+	if err == nil {
+		ver := rl.GetTagName()
+		if semver.IsValid(ver) && semver.Prerelease(ver) == "" {
+			return ver, nil
+		}
+	} else if ge, ok := err.(*github.ErrorResponse); !ok || ge.Response == nil || ge.Response.StatusCode != http.StatusNotFound {
+		return "", err
+	}
+
+	stable, _, _, err := GHReleasesWithOptions(ctx, owner, repo, opts)
 	if err != nil || !semver.IsValid(stable.Tag) {
 		return "", err
 	}
 	return stable.Tag, nil
 }
+
+// GHReleaseNotesBetween returns the aggregated release notes for every release strictly after
+// fromTag and up to and including toTag, ordered from oldest to newest, and any error occurred.
+// Only the newest ghSearchLimit releases are searched; older releases in the range are omitted.
+func GHReleaseNotesBetween(ctx context.Context, owner, repo, fromTag, toTag string) (string, error) {
+	return GHReleaseNotesBetweenWithOptions(ctx, owner, repo, fromTag, toTag, Options{})
+}
+
+// GHReleaseNotesBetweenWithOptions behaves like GHReleaseNotesBetween but allows the caller to
+// customize authentication, retry behaviour and pagination via opts.
+func GHReleaseNotesBetweenWithOptions(ctx context.Context, owner, repo, fromTag, toTag string, opts Options) (string, error) {
+	ghc := ghClient(ctx, opts)
+	perPage := opts.PerPage
+	if perPage <= 0 {
+		perPage = ghListPerPage
+	}
+	searchLimit := opts.SearchLimit
+	if searchLimit <= 0 {
+		searchLimit = ghSearchLimit
+	}
+
+	var notes []Release
+	opt := &github.ListOptions{PerPage: perPage}
+	for (opt.Page+1)*perPage <= searchLimit {
+		var rls []*github.RepositoryRelease
+		var resp *github.Response
+		err := ghRetry(ctx, opts, func() (*github.Response, error) {
+			var ferr error
+			rls, resp, ferr = ghc.Repositories.ListReleases(ctx, owner, repo, opt)
+			return resp, ferr
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, rl := range rls {
+			ver := rl.GetTagName()
+			if !semver.IsValid(ver) || semver.Compare(ver, fromTag) <= 0 || semver.Compare(ver, toTag) > 0 {
+				continue
+			}
+			notes = append(notes, Release{Tag: ver, Notes: rl.GetBody()})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	sort.Slice(notes, func(i, j int) bool { return semver.Compare(notes[i].Tag, notes[j].Tag) < 0 })
+
+	var b strings.Builder
+	for _, n := range notes {
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", n.Tag, n.Notes)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}